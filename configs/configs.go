@@ -6,6 +6,8 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/xinliangnote/go-gin-api/pkg/env"
@@ -15,7 +17,33 @@ import (
 	"github.com/spf13/viper"
 )
 
-var config = new(Config)
+// config 持有当前生效的配置快照，用 atomic.Value 保证并发读取安全，
+// 每次 viper 检测到文件变化都会整体替换一份新的快照，而不是就地修改
+var config atomic.Value
+
+var (
+	subscribersMu sync.Mutex
+	subscribers   []func(old, new Config)
+)
+
+// MySQLPoolConfig 连接池相关参数，单独命名成一个类型是为了让 main.go 里的
+// mysqlPoolReconfigurer 接口能直接引用它，不用依赖 Config 内部的匿名结构体
+type MySQLPoolConfig struct {
+	MaxOpenConn     int           `toml:"maxOpenConn"`
+	MaxIdleConn     int           `toml:"maxIdleConn"`
+	ConnMaxLifeTime time.Duration `toml:"connMaxLifeTime"`
+}
+
+// RedisConfig redis 客户端的连接参数，单独命名成一个类型是为了让 main.go 里的
+// redisReconfigurer 接口能直接引用它
+type RedisConfig struct {
+	Addr         string `toml:"addr"`
+	Pass         string `toml:"pass"`
+	Db           int    `toml:"db"`
+	MaxRetries   int    `toml:"maxRetries"`
+	PoolSize     int    `toml:"poolSize"`
+	MinIdleConns int    `toml:"minIdleConns"`
+}
 
 // Config 配置文件结构体，参考fat_configs.toml
 type Config struct {
@@ -32,21 +60,10 @@ type Config struct {
 			Pass string `toml:"pass"`
 			Name string `toml:"name"`
 		} `toml:"write"`
-		Base struct {
-			MaxOpenConn     int           `toml:"maxOpenConn"`
-			MaxIdleConn     int           `toml:"maxIdleConn"`
-			ConnMaxLifeTime time.Duration `toml:"connMaxLifeTime"`
-		} `toml:"base"`
+		Base MySQLPoolConfig `toml:"base"`
 	} `toml:"mysql"`
 
-	Redis struct {
-		Addr         string `toml:"addr"`
-		Pass         string `toml:"pass"`
-		Db           int    `toml:"db"`
-		MaxRetries   int    `toml:"maxRetries"`
-		PoolSize     int    `toml:"poolSize"`
-		MinIdleConns int    `toml:"minIdleConns"`
-	} `toml:"redis"`
+	Redis RedisConfig `toml:"redis"`
 
 	Mail struct {
 		Host string `toml:"host"`
@@ -64,6 +81,27 @@ type Config struct {
 	Language struct {
 		Local string `toml:"local"`
 	} `toml:"language"`
+
+	Report struct {
+		// Type IM 报警渠道：lark / wecom / telegram，留空表示不开启
+		Type string `toml:"type"`
+		// Token 群机器人的 webhook token（telegram 时是 bot token）
+		Token string `toml:"token"`
+		// ChatID telegram 专用，群/频道 id
+		ChatID string `toml:"chatId"`
+	} `toml:"report"`
+
+	Loki struct {
+		// Endpoint loki 的地址，例如 http://127.0.0.1:3100，留空表示不开启
+		Endpoint string `toml:"endpoint"`
+		// Job 对应 loki 的 job 标签
+		Job string `toml:"job"`
+	} `toml:"loki"`
+
+	Log struct {
+		// Level 日志级别：debug/info/warn/error，支持热更新
+		Level string `toml:"level"`
+	} `toml:"log"`
 }
 
 // 这里使用了go1.16的embed特性，将配置文件内容嵌入到变量中
@@ -104,9 +142,11 @@ func init() {
 		panic(err)
 	}
 	// 将配置文件内容反序列化到config结构体中
-	if err := viper.Unmarshal(config); err != nil {
+	cfg := new(Config)
+	if err := viper.Unmarshal(cfg); err != nil {
 		panic(err)
 	}
+	config.Store(*cfg)
 	// 关联具体的配置文件，便于后面监听配置文件变化
 	viper.SetConfigName(env.Active().Value() + "_configs")
 	viper.AddConfigPath("./configs")
@@ -128,15 +168,42 @@ func init() {
 			panic(err)
 		}
 	}
-	// 监听配置文件变化，如果变化，将新的内容反序列化到config结构体中
+	// 监听配置文件变化，如果变化，将新的内容反序列化后整体替换快照，并通知所有订阅者
 	viper.WatchConfig()
 	viper.OnConfigChange(func(e fsnotify.Event) {
-		if err := viper.Unmarshal(config); err != nil {
+		newCfg := new(Config)
+		if err := viper.Unmarshal(newCfg); err != nil {
 			panic(err)
 		}
+
+		old := Get()
+		config.Store(*newCfg)
+		notifySubscribers(old, *newCfg)
 	})
 }
 
+// Get 返回当前生效配置的一份快照，并发安全，可以放心长期持有
 func Get() Config {
-	return *config
+	return config.Load().(Config)
+}
+
+// Subscribe 注册一个配置变更回调，每次 viper 检测到配置文件变化后都会被调用一次，
+// 用于让 db 连接池大小、redis、日志级别等子系统在不重启进程的情况下感知变化；
+// main.go 里注册了日志级别，以及 db 连接池、redis 的订阅者
+func Subscribe(fn func(old, new Config)) {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+
+	subscribers = append(subscribers, fn)
+}
+
+func notifySubscribers(old, new Config) {
+	subscribersMu.Lock()
+	fns := make([]func(old, new Config), len(subscribers))
+	copy(fns, subscribers)
+	subscribersMu.Unlock()
+
+	for _, fn := range fns {
+		fn(old, new)
+	}
 }
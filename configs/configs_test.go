@@ -0,0 +1,72 @@
+package configs
+
+import (
+	"testing"
+)
+
+// resetSubscribers 避免不同测试之间互相污染全局的 subscribers 列表
+func resetSubscribers(t *testing.T) {
+	t.Helper()
+	subscribersMu.Lock()
+	prev := subscribers
+	subscribers = nil
+	subscribersMu.Unlock()
+
+	t.Cleanup(func() {
+		subscribersMu.Lock()
+		subscribers = prev
+		subscribersMu.Unlock()
+	})
+}
+
+func TestGetReturnsStoredSnapshot(t *testing.T) {
+	prev := config.Load()
+	t.Cleanup(func() { config.Store(prev) })
+
+	var want Config
+	want.Log.Level = "debug"
+	config.Store(want)
+
+	got := Get()
+	if got.Log.Level != "debug" {
+		t.Fatalf("Get().Log.Level = %q, want %q", got.Log.Level, "debug")
+	}
+}
+
+func TestSubscribeNotifiesWithOldAndNew(t *testing.T) {
+	resetSubscribers(t)
+
+	var gotOld, gotNew Config
+	var calls int
+	Subscribe(func(old, new Config) {
+		calls++
+		gotOld, gotNew = old, new
+	})
+
+	var oldCfg, newCfg Config
+	oldCfg.Log.Level = "info"
+	newCfg.Log.Level = "warn"
+
+	notifySubscribers(oldCfg, newCfg)
+
+	if calls != 1 {
+		t.Fatalf("subscriber called %d times, want 1", calls)
+	}
+	if gotOld.Log.Level != "info" || gotNew.Log.Level != "warn" {
+		t.Fatalf("subscriber got old=%+v new=%+v, want old.Log.Level=info new.Log.Level=warn", gotOld, gotNew)
+	}
+}
+
+func TestSubscribeFanOutToMultipleSubscribers(t *testing.T) {
+	resetSubscribers(t)
+
+	var firstCalled, secondCalled bool
+	Subscribe(func(old, new Config) { firstCalled = true })
+	Subscribe(func(old, new Config) { secondCalled = true })
+
+	notifySubscribers(Config{}, Config{})
+
+	if !firstCalled || !secondCalled {
+		t.Fatalf("expected both subscribers to be notified, first=%v second=%v", firstCalled, secondCalled)
+	}
+}
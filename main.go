@@ -2,18 +2,24 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/xinliangnote/go-gin-api/configs"
+	"github.com/xinliangnote/go-gin-api/internal/middleware"
 	"github.com/xinliangnote/go-gin-api/internal/router"
 	"github.com/xinliangnote/go-gin-api/pkg/env"
 	"github.com/xinliangnote/go-gin-api/pkg/logger"
+	"github.com/xinliangnote/go-gin-api/pkg/logger/report"
 	"github.com/xinliangnote/go-gin-api/pkg/shutdown"
 	"github.com/xinliangnote/go-gin-api/pkg/timeutil"
 
+	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 // @title swagger 接口文档
@@ -31,19 +37,57 @@ import (
 // @in                          header
 // @name                        token
 
+// mysqlPoolReconfigurer 是 s.Db 可以选择性实现的接口：实现了它，配置热更新时
+// mysql 连接池参数（最大连接数、最大空闲连接数、连接最大生命周期）会被直接应用，
+// 不用重启进程
+type mysqlPoolReconfigurer interface {
+	ApplyPoolConfig(cfg configs.MySQLPoolConfig)
+}
+
+// redisReconfigurer 是 s.Cache 可以选择性实现的接口：实现了它，配置热更新时
+// redis 客户端会按新的地址/密码/连接池参数重新连接，不用重启进程
+type redisReconfigurer interface {
+	ApplyConfig(ctx context.Context, cfg configs.RedisConfig) error
+}
+
 // @BasePath /
 func main() {
 	// 初始化 access logger
-	accessLogger, err := logger.NewJSONLogger(
+	accessLoggerOpts := []logger.Option{
 		// 禁用控制台输出
 		logger.WithDisableConsole(),
 		// 日志添加domain字段，添加项目名和环境标识
 		logger.WithField("domain", fmt.Sprintf("%s[%s]", configs.ProjectName, env.Active().Value())),
 		// 指定日志输出格式
 		logger.WithTimeLayout(timeutil.CSTLayout),
-		// 日志输出到文件
-		logger.WithFileP(configs.ProjectAccessLogFile),
-	)
+		// 异步写文件，避免高并发下请求被磁盘 IO 拖慢
+		logger.WithAsyncFile(configs.ProjectAccessLogFile, logger.AsyncOptions{
+			QueueSize:     10000,
+			FlushInterval: 200 * time.Millisecond,
+			DropPolicy:    logger.DropPolicyDropOldest,
+			SyncTimeout:   5 * time.Second,
+		}),
+		// 采样：每 1 秒的窗口内，同一条日志前 100 条全部输出，超过之后每 100 条才出 1 条
+		logger.WithSampling(100, 100, time.Second),
+		// 同一个调用处每秒最多输出 50 条，超过的部分合并成一条 suppressed 汇总日志
+		logger.WithCallerRateLimit(50),
+	}
+	// 配置了 report 才开启 IM 报警，错误及以上级别的日志会被转发到对应的机器人
+	if rc := configs.Get().Report; rc.Token != "" {
+		accessLoggerOpts = append(accessLoggerOpts, logger.WithAlertReporter(report.Config{
+			Type:   report.Type(rc.Type),
+			Token:  rc.Token,
+			ChatID: rc.ChatID,
+		}))
+	}
+	// 配置了 loki 才额外镜像写一份到 loki，方便统一检索
+	if lc := configs.Get().Loki; lc.Endpoint != "" {
+		accessLoggerOpts = append(accessLoggerOpts, logger.WithLoki(logger.LokiConfig{
+			Endpoint: lc.Endpoint,
+			Job:      lc.Job,
+		}))
+	}
+	accessLogger, err := logger.NewJSONLogger(accessLoggerOpts...)
 	if err != nil {
 		panic(err)
 	}
@@ -53,17 +97,63 @@ func main() {
 		logger.WithDisableConsole(),
 		logger.WithField("domain", fmt.Sprintf("%s[%s]", configs.ProjectName, env.Active().Value())),
 		logger.WithTimeLayout(timeutil.CSTLayout),
-		logger.WithFileP(configs.ProjectCronLogFile),
+		logger.WithAsyncFile(configs.ProjectCronLogFile, logger.AsyncOptions{
+			QueueSize:     10000,
+			FlushInterval: 200 * time.Millisecond,
+			DropPolicy:    logger.DropPolicyDropOldest,
+			SyncTimeout:   5 * time.Second,
+		}),
+		logger.WithSampling(100, 100, time.Second),
+		logger.WithCallerRateLimit(50),
 	)
 
 	if err != nil {
 		panic(err)
 	}
-	// 服务关闭时，手动调用zap.Logger.Sync方法将缓冲区的日志追加到文件中
-	defer func() {
-		_ = accessLogger.Sync()
-		_ = cronLogger.Sync()
-	}()
+
+	// 初始日志级别以配置文件为准，并注册订阅，配置热更新时动态调整级别，无需重启进程。
+	if lvl, err := zapcore.ParseLevel(configs.Get().Log.Level); err == nil {
+		accessLogger.SetLevel(lvl)
+		cronLogger.SetLevel(lvl)
+	}
+	configs.Subscribe(func(old, new configs.Config) {
+		if old.Log.Level == new.Log.Level {
+			return
+		}
+
+		lvl, err := zapcore.ParseLevel(new.Log.Level)
+		if err != nil {
+			accessLogger.Error("invalid log level from config", zap.String("level", new.Log.Level), zap.Error(err))
+			return
+		}
+
+		accessLogger.SetLevel(lvl)
+		cronLogger.SetLevel(lvl)
+	})
+	// db 连接池大小、redis 这两个子系统的具体客户端由 internal/router 构建和持有，
+	// 这里不知道也不关心 s.Db / s.Cache 背后具体是什么库，用 duck typing 做可选适配：
+	// 实现了对应接口就真正热更新生效，没实现就退化成提醒运维重启，而不是悄悄什么都不做
+	configs.Subscribe(func(old, new configs.Config) {
+		if old.MySQL.Base != new.MySQL.Base {
+			if r, ok := s.Db.(mysqlPoolReconfigurer); ok {
+				r.ApplyPoolConfig(new.MySQL.Base)
+			} else {
+				accessLogger.Warn("mysql pool config changed but the db client doesn't support hot-reload, restart the process to apply it")
+			}
+		}
+		if old.Redis != new.Redis {
+			if r, ok := s.Cache.(redisReconfigurer); ok {
+				redisCtx, redisCancel := context.WithTimeout(context.Background(), 5*time.Second)
+				err := r.ApplyConfig(redisCtx, new.Redis)
+				redisCancel()
+				if err != nil {
+					accessLogger.Error("failed to apply redis config hot-reload", zap.Error(err))
+				}
+			} else {
+				accessLogger.Warn("redis config changed but the cache client doesn't support hot-reload, restart the process to apply it")
+			}
+		}
+	})
 
 	// 初始化 HTTP 服务
 	s, err := router.NewHTTPServer(accessLogger, cronLogger)
@@ -71,6 +161,11 @@ func main() {
 		panic(err)
 	}
 
+	// 运行时查看/调整日志级别的管理接口，套上已有的 token 中间件，避免被匿名访问
+	logLevelHandler := gin.WrapF(logger.LevelHandler(accessLogger))
+	s.Mux.GET("/system/log/level", middleware.CheckLogin(), logLevelHandler)
+	s.Mux.PUT("/system/log/level", middleware.CheckLogin(), logLevelHandler)
+
 	server := &http.Server{
 		Addr:    configs.ProjectPort,
 		Handler: s.Mux,
@@ -82,47 +177,63 @@ func main() {
 		}
 	}()
 
-	// 优雅关闭
-	shutdown.NewHook().Close(
-		// 关闭 http server
-		func() {
-			// 设置 10 秒超时时间的ctx
-			ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
-			defer cancel()
-
+	// 优雅关闭，priority 越小越先执行：先让 http server 停止接收新请求，再关 db/cache，
+	// 然后停 cron，最后把日志相关的异步队列排干
+	h := shutdown.NewHook().
+		Register("http-server", 10, 10*time.Second, func(ctx context.Context) error {
 			// TODO 后面的一系列关闭操作是否可以使用 server.RegisterOnShutdown() 来实现,RegisterOnShutdown注册的方法会在shutdown的最后执行
-			if err := server.Shutdown(ctx); err != nil {
-				accessLogger.Error("server shutdown err", zap.Error(err))
+			return server.Shutdown(ctx)
+		}).
+		Register("db", 20, 10*time.Second, func(ctx context.Context) error {
+			if s.Db == nil {
+				return nil
 			}
-		},
 
-		// 关闭 db
-		func() {
-			if s.Db != nil {
-				if err := s.Db.DbWClose(); err != nil {
-					accessLogger.Error("dbw close err", zap.Error(err))
-				}
-
-				if err := s.Db.DbRClose(); err != nil {
-					accessLogger.Error("dbr close err", zap.Error(err))
-				}
+			if err := s.Db.DbWClose(); err != nil {
+				return err
 			}
-		},
-
-		// 关闭 cache
-		func() {
-			if s.Cache != nil {
-				if err := s.Cache.Close(); err != nil {
-					accessLogger.Error("cache close err", zap.Error(err))
-				}
+			return s.Db.DbRClose()
+		}).
+		Register("cache", 21, 10*time.Second, func(ctx context.Context) error {
+			if s.Cache == nil {
+				return nil
 			}
-		},
-
-		// 关闭 cron Server
-		func() {
+			return s.Cache.Close()
+		}).
+		Register("cron", 30, 10*time.Second, func(ctx context.Context) error {
 			if s.CronServer != nil {
 				s.CronServer.Stop()
 			}
-		},
-	)
+			return nil
+		}).
+		Register("logger", 40, 10*time.Second, func(ctx context.Context) error {
+			// 把 loki、IM 报警等异步 sink 里还没推送完的日志 flush 出去，再停掉对应的后台协程；
+			// 传入 ctx 让这一步的网络请求真正受这个钩子自己的 10s timeout 约束。
+			// accessLogger 和 cronLogger 互不依赖，哪怕 accessLogger 这一步超时出错，
+			// cronLogger 也要照常 flush/close，不能因为先做的那个失败就把后面几步跳过
+			var errs []error
+			if err := accessLogger.Flush(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("access logger flush: %w", err))
+			}
+			if err := cronLogger.Flush(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("cron logger flush: %w", err))
+			}
+			if err := accessLogger.Close(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("access logger close: %w", err))
+			}
+			if err := cronLogger.Close(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("cron logger close: %w", err))
+			}
+			return errors.Join(errs...)
+		})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := h.Close(ctx); err != nil {
+		// 不能再走 accessLogger：priority 40 的 "logger" 钩子已经在上面的 h.Close 里把它的
+		// 异步队列/后台协程关掉了，这时候再 Error() 进去只会悄悄卡在一个没有消费者的 channel
+		// 里，永远不会落盘或上报，进程退出时也看不到任何痕迹。这是进程最后一行，直接走 stderr。
+		fmt.Fprintf(os.Stderr, "shutdown err: %v\n", err)
+	}
 }
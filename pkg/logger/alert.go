@@ -0,0 +1,102 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/xinliangnote/go-gin-api/pkg/logger/report"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// alertCore 把符合条件的日志条目转发给 report.Reporter，由它负责聚合、去重、上报
+
+type alertCore struct {
+	reporter *report.Reporter
+	fields   map[string]interface{}
+}
+
+func newAlertCore(reporter *report.Reporter) zapcore.Core {
+	return &alertCore{reporter: reporter, fields: make(map[string]interface{})}
+}
+
+func (c *alertCore) Enabled(level zapcore.Level) bool {
+	return c.reporter.Enabled(level)
+}
+
+func (c *alertCore) With(fields []zapcore.Field) zapcore.Core {
+	merged := make(map[string]interface{}, len(c.fields)+len(fields))
+	for k, v := range c.fields {
+		merged[k] = v
+	}
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	for k, v := range enc.Fields {
+		merged[k] = v
+	}
+	return &alertCore{reporter: c.reporter, fields: merged}
+}
+
+func (c *alertCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *alertCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	merged := make(map[string]interface{}, len(c.fields)+len(enc.Fields))
+	for k, v := range c.fields {
+		merged[k] = v
+	}
+	for k, v := range enc.Fields {
+		merged[k] = v
+	}
+
+	c.reporter.Push(report.Entry{
+		Level:      ent.Level,
+		Msg:        ent.Message,
+		Caller:     ent.Caller.String(),
+		Stacktrace: takeStacktrace(),
+		Fields:     merged,
+	})
+	return nil
+}
+
+// takeStacktrace 现抓一份调用栈，跳过 takeStacktrace、alertCore.Write 本身和 zap 包内的帧，只给发去 IM 的报警用；
+// logger 本身没有开 zap.AddStacktrace（那是全局开关，会让每条 Error 日志都带上堆栈，
+// 参见 NewJSONLogger 里的注释），所以这里单独抓，不影响控制台/文件/loki 这几路 core
+func takeStacktrace() string {
+	pc := make([]uintptr, 64)
+	n := runtime.Callers(3, pc)
+	if n == 0 {
+		return ""
+	}
+
+	frames := runtime.CallersFrames(pc[:n])
+	var sb strings.Builder
+	for {
+		frame, more := frames.Next()
+		if !strings.Contains(frame.Function, "go.uber.org/zap") {
+			fmt.Fprintf(&sb, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		}
+		if !more {
+			break
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func (c *alertCore) Sync() error {
+	// zapcore.Core.Sync() 没有 ctx 参数，这里没有调用方可以传入的 deadline，
+	// 用 Background；真正有超时预算的退出路径走 Logger.Close(ctx)
+	return c.reporter.Flush(context.Background())
+}
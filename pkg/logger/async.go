@@ -0,0 +1,244 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// DropPolicy 队列写满之后怎么处理新进来的日志
+type DropPolicy int
+
+const (
+	// DropPolicyBlock 队列满了就阻塞调用方，直到腾出空间（保证不丢日志，但可能拖慢业务）
+	DropPolicyBlock DropPolicy = iota
+	// DropPolicyDropOldest 队列满了丢弃最老的一条，腾出空间给新日志
+	DropPolicyDropOldest
+	// DropPolicyDropNewest 队列满了直接丢弃当前这条新日志
+	DropPolicyDropNewest
+)
+
+// AsyncOptions WithAsyncFile 的配置
+type AsyncOptions struct {
+	// QueueSize 环形缓冲区大小，单位：条
+	QueueSize int
+	// FlushInterval 后台协程除了逐条取出日志落盘之外，每隔多久再把缓冲区整个排空一次
+	FlushInterval time.Duration
+	// DropPolicy 缓冲区写满之后的处理策略，默认 DropPolicyBlock
+	DropPolicy DropPolicy
+	// SyncTimeout logger.Sync() 等待缓冲区排空的最长时间，<=0 表示不设超时，一直等到排空为止
+	SyncTimeout time.Duration
+}
+
+func (o *AsyncOptions) withDefaults() {
+	if o.QueueSize <= 0 {
+		o.QueueSize = 10000
+	}
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = 200 * time.Millisecond
+	}
+}
+
+// Stats 是日志系统里各类背压/丢弃指标的快照，命名风格参考 prometheus 的指标名
+type Stats struct {
+	// LogDroppedTotal 因为队列满被丢弃的日志条数
+	LogDroppedTotal uint64
+	// LogQueueDepth 当前异步队列里还没落盘的日志条数
+	LogQueueDepth int
+	// LogFlushLatencySeconds 最近一次把单条日志写入底层 writer 花费的时间，单位秒
+	LogFlushLatencySeconds float64
+	// LogSampledDroppedTotal 被 WithSampling 采样丢弃的日志条数
+	LogSampledDroppedTotal uint64
+	// LogRateLimitedTotal 被 WithCallerRateLimit 按调用处限流丢弃的日志条数
+	LogRateLimitedTotal uint64
+}
+
+// WithAsyncFile 把日志写到文件这件事放到后台协程做，Write 调用本身只是把日志塞进一个有界的
+// 环形缓冲区，不会阻塞在磁盘 IO 上；path 为空时行为等价于 WithFileRotationP 的切割策略
+func WithAsyncFile(path string, opts AsyncOptions) Option {
+	opts.withDefaults()
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0766); err != nil {
+		panic(err)
+	}
+
+	return func(opt *option) {
+		rotate := &lumberjack.Logger{
+			Filename:   path,
+			MaxSize:    128,
+			MaxBackups: 300,
+			MaxAge:     30,
+			LocalTime:  true,
+			Compress:   true,
+		}
+		opt.file = newAsyncWriter(rotate, opts)
+	}
+}
+
+// asyncWriter 包装一个底层 io.Writer（通常是 lumberjack），对外表现为同步写，
+// 实际上只是把数据拷贝进队列，真正的落盘由后台协程串行完成，从而保证写入顺序
+type asyncWriter struct {
+	opts AsyncOptions
+	w    io.Writer
+
+	queue  chan []byte
+	flushC chan chan struct{}
+	closeC chan struct{}
+	wg     sync.WaitGroup
+
+	dropped          uint64
+	flushLatencyBits uint64 // atomic 存放 float64 的 bit pattern
+}
+
+func newAsyncWriter(w io.Writer, opts AsyncOptions) *asyncWriter {
+	a := &asyncWriter{
+		opts:   opts,
+		w:      w,
+		queue:  make(chan []byte, opts.QueueSize),
+		flushC: make(chan chan struct{}),
+		closeC: make(chan struct{}),
+	}
+
+	a.wg.Add(1)
+	go a.loop()
+
+	return a
+}
+
+// Write 实现 io.Writer，拷贝一份数据后非阻塞（或按 DropPolicy）地塞进队列就返回
+func (a *asyncWriter) Write(p []byte) (int, error) {
+	buf := make([]byte, len(p))
+	copy(buf, p)
+
+	switch a.opts.DropPolicy {
+	case DropPolicyDropNewest:
+		select {
+		case a.queue <- buf:
+		default:
+			atomic.AddUint64(&a.dropped, 1)
+		}
+	case DropPolicyDropOldest:
+		select {
+		case a.queue <- buf:
+		default:
+			select {
+			case <-a.queue:
+				atomic.AddUint64(&a.dropped, 1)
+			default:
+			}
+			select {
+			case a.queue <- buf:
+			default:
+				atomic.AddUint64(&a.dropped, 1)
+			}
+		}
+	default: // DropPolicyBlock
+		a.queue <- buf
+	}
+
+	return len(p), nil
+}
+
+func (a *asyncWriter) loop() {
+	defer a.wg.Done()
+
+	ticker := time.NewTicker(a.opts.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case buf := <-a.queue:
+			a.writeOne(buf)
+		case <-ticker.C:
+			a.drainOnce()
+		case ack := <-a.flushC:
+			a.drainOnce()
+			close(ack)
+		case <-a.closeC:
+			a.drainOnce()
+			return
+		}
+	}
+}
+
+func (a *asyncWriter) writeOne(buf []byte) {
+	start := time.Now()
+	_, _ = a.w.Write(buf)
+	atomic.StoreUint64(&a.flushLatencyBits, math.Float64bits(time.Since(start).Seconds()))
+}
+
+// drainOnce 把当前已经在队列里的日志一次性取完写掉，不等待之后才到达的新日志
+func (a *asyncWriter) drainOnce() {
+	for {
+		select {
+		case buf := <-a.queue:
+			a.writeOne(buf)
+		default:
+			return
+		}
+	}
+}
+
+// Sync 阻塞直到队列排空，或者等待超过 SyncTimeout，让 zap.Logger.Sync() 有确定性的退出点
+func (a *asyncWriter) Sync() error {
+	ack := make(chan struct{})
+
+	if a.opts.SyncTimeout <= 0 {
+		a.flushC <- ack
+		<-ack
+		return nil
+	}
+
+	timer := time.NewTimer(a.opts.SyncTimeout)
+	defer timer.Stop()
+
+	select {
+	case a.flushC <- ack:
+	case <-timer.C:
+		return fmt.Errorf("logger: async file sync timed out after %s", a.opts.SyncTimeout)
+	}
+
+	select {
+	case <-ack:
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("logger: async file sync timed out after %s", a.opts.SyncTimeout)
+	}
+}
+
+// close 让后台协程做最后一次 drainOnce 之后退出；ctx 给这次排空一个截止时间，
+// 避免 a.w（lumberjack）落盘卡住时把 Logger.Close(ctx) 一直拖住
+func (a *asyncWriter) close(ctx context.Context) error {
+	close(a.closeC)
+
+	done := make(chan struct{})
+	go func() {
+		a.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("logger: async file close timed out: %w", ctx.Err())
+	}
+}
+
+// Stats 返回当前的背压/丢弃指标快照
+func (a *asyncWriter) Stats() Stats {
+	return Stats{
+		LogDroppedTotal:        atomic.LoadUint64(&a.dropped),
+		LogQueueDepth:          len(a.queue),
+		LogFlushLatencySeconds: math.Float64frombits(atomic.LoadUint64(&a.flushLatencyBits)),
+	}
+}
@@ -0,0 +1,74 @@
+package logger
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+// discardWriter 是一个什么都不做的 io.Writer，用来把基准测试的瓶颈限制在
+// 同步/异步写入路径本身，而不是实际的磁盘 IO 上
+type discardWriter struct{}
+
+func (discardWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+// benchLine 模拟一条约 10k msg/sec 场景下典型的 JSON 日志行
+var benchLine = []byte(`{"level":"info","time":"2024-01-01T00:00:00Z","msg":"request completed","caller":"handler.go:42"}` + "\n")
+
+// BenchmarkSyncWrite 模拟原先直接写 io.Writer（同步阻塞在 IO 上）的路径
+func BenchmarkSyncWrite(b *testing.B) {
+	var w io.Writer = discardWriter{}
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchLine)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, _ = w.Write(benchLine)
+	}
+}
+
+// BenchmarkAsyncWrite 模拟 WithAsyncFile 的路径：Write 只把数据塞进队列，
+// 真正落盘由后台协程完成
+func BenchmarkAsyncWrite(b *testing.B) {
+	a := newAsyncWriter(discardWriter{}, AsyncOptions{
+		QueueSize:     10000,
+		FlushInterval: 200 * time.Millisecond,
+		DropPolicy:    DropPolicyDropOldest,
+		SyncTimeout:   5 * time.Second,
+	})
+	defer a.close(context.Background())
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchLine)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, _ = a.Write(benchLine)
+	}
+}
+
+// BenchmarkAsyncWriteParallel 在并发场景下对比 async 路径，近似 10k msg/sec
+// 这种量级下多个 goroutine 同时写日志的情况
+func BenchmarkAsyncWriteParallel(b *testing.B) {
+	a := newAsyncWriter(discardWriter{}, AsyncOptions{
+		QueueSize:     10000,
+		FlushInterval: 200 * time.Millisecond,
+		DropPolicy:    DropPolicyDropOldest,
+		SyncTimeout:   5 * time.Second,
+	})
+	defer a.close(context.Background())
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchLine)))
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, _ = a.Write(benchLine)
+		}
+	})
+}
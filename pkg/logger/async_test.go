@@ -0,0 +1,120 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingWriter 在 unblock 关闭之前一直卡住 Write，用来模拟队列写满时后台协程
+// 还没来得及消费的场景
+type blockingWriter struct {
+	unblock chan struct{}
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.unblock
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *blockingWriter) written() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Len()
+}
+
+func TestAsyncWriterDropPolicyBlock(t *testing.T) {
+	w := &blockingWriter{unblock: make(chan struct{})}
+	a := newAsyncWriter(w, AsyncOptions{QueueSize: 1, FlushInterval: time.Hour, DropPolicy: DropPolicyBlock})
+	defer func() { _ = a.close(context.Background()) }()
+
+	_, _ = a.Write([]byte("a"))       // 被后台协程立刻取走在 writeOne 里卡住
+	time.Sleep(20 * time.Millisecond) // 让后台协程把 "a" 从 queue 里取走，腾出唯一的槽位
+	_, _ = a.Write([]byte("b"))       // 填满 QueueSize=1 的队列
+
+	done := make(chan struct{})
+	go func() {
+		_, _ = a.Write([]byte("c")) // 队列已满，应该阻塞直到腾出空间
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("Write with DropPolicyBlock returned before queue had room")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(w.unblock)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Write with DropPolicyBlock never unblocked after queue drained")
+	}
+
+	if got := a.Stats().LogDroppedTotal; got != 0 {
+		t.Fatalf("DropPolicyBlock must never drop, got LogDroppedTotal=%d", got)
+	}
+}
+
+func TestAsyncWriterDropPolicyDropNewest(t *testing.T) {
+	w := &blockingWriter{unblock: make(chan struct{})}
+	a := newAsyncWriter(w, AsyncOptions{QueueSize: 1, FlushInterval: time.Hour, DropPolicy: DropPolicyDropNewest})
+	defer func() { _ = a.close(context.Background()) }()
+
+	_, _ = a.Write([]byte("a"))       // 立刻被后台协程取走，卡在 writeOne 里
+	time.Sleep(20 * time.Millisecond) // 腾出唯一的队列槽位
+	_, _ = a.Write([]byte("b"))       // 填满队列
+	_, _ = a.Write([]byte("c"))       // 队列已满，新的一条应该被直接丢弃
+
+	if got := a.Stats().LogDroppedTotal; got != 1 {
+		t.Fatalf("expected 1 dropped entry, got %d", got)
+	}
+
+	close(w.unblock)
+	waitForQueueDrain(t, a)
+
+	if got := w.written(); got != len("ab") {
+		t.Fatalf("expected only the first two writes to land, got %d bytes written", got)
+	}
+}
+
+func TestAsyncWriterDropPolicyDropOldest(t *testing.T) {
+	w := &blockingWriter{unblock: make(chan struct{})}
+	a := newAsyncWriter(w, AsyncOptions{QueueSize: 1, FlushInterval: time.Hour, DropPolicy: DropPolicyDropOldest})
+	defer func() { _ = a.close(context.Background()) }()
+
+	_, _ = a.Write([]byte("a"))       // 立刻被后台协程取走，卡在 writeOne 里
+	time.Sleep(20 * time.Millisecond) // 腾出唯一的队列槽位
+	_, _ = a.Write([]byte("b"))       // 填满队列
+	_, _ = a.Write([]byte("c"))       // 队列已满，应该丢掉 "b"，把 "c" 留下
+
+	if got := a.Stats().LogDroppedTotal; got != 1 {
+		t.Fatalf("expected 1 dropped entry, got %d", got)
+	}
+
+	close(w.unblock)
+	waitForQueueDrain(t, a)
+
+	if got := w.written(); got != len("a")+len("c") {
+		t.Fatalf("expected oldest pending entry to be dropped in favor of the newest, got %d bytes written", got)
+	}
+}
+
+func waitForQueueDrain(t *testing.T, a *asyncWriter) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if a.Stats().LogQueueDepth == 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("queue never drained")
+}
@@ -0,0 +1,53 @@
+package logger
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// levelRequest PUT /system/log/level 的请求体
+type levelRequest struct {
+	Level string `json:"level"`
+}
+
+// levelResponse GET/PUT /system/log/level 的响应体
+type levelResponse struct {
+	Level string `json:"level"`
+}
+
+// LevelHandler 暴露一个 GET 查看/PUT 修改运行时日志级别的 http.HandlerFunc，
+// GET 返回当前级别，PUT 设置新的级别；返回的是标准库 http.HandlerFunc，调用方需要
+// 用 gin.WrapF 包一层再挂到路由上，并自行套上鉴权中间件（见 main.go 中
+// /system/log/level 的注册方式，套了已有的 token 中间件）
+func LevelHandler(l *Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeLevelJSON(w, l.Level())
+		case http.MethodPut:
+			var req levelRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			level, err := zapcore.ParseLevel(req.Level)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			l.SetLevel(level)
+			writeLevelJSON(w, l.Level())
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func writeLevelJSON(w http.ResponseWriter, level zapcore.Level) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(levelResponse{Level: level.String()})
+}
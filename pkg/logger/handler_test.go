@@ -0,0 +1,109 @@
+package logger
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func newTestLogger(t *testing.T) *Logger {
+	t.Helper()
+	l, err := NewJSONLogger(WithInfoLevel())
+	if err != nil {
+		t.Fatalf("NewJSONLogger() error = %v", err)
+	}
+	return l
+}
+
+func TestLevelHandlerGet(t *testing.T) {
+	l := newTestLogger(t)
+	h := LevelHandler(l)
+
+	req := httptest.NewRequest(http.MethodGet, "/system/log/level", nil)
+	rec := httptest.NewRecorder()
+	h(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var resp levelResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Level != zapcore.InfoLevel.String() {
+		t.Fatalf("Level = %q, want %q", resp.Level, zapcore.InfoLevel.String())
+	}
+}
+
+func TestLevelHandlerPutChangesLevel(t *testing.T) {
+	l := newTestLogger(t)
+	h := LevelHandler(l)
+
+	body := strings.NewReader(`{"level":"error"}`)
+	req := httptest.NewRequest(http.MethodPut, "/system/log/level", body)
+	rec := httptest.NewRecorder()
+	h(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got := l.Level(); got != zapcore.ErrorLevel {
+		t.Fatalf("logger level = %v, want %v", got, zapcore.ErrorLevel)
+	}
+
+	var resp levelResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Level != zapcore.ErrorLevel.String() {
+		t.Fatalf("Level = %q, want %q", resp.Level, zapcore.ErrorLevel.String())
+	}
+}
+
+func TestLevelHandlerPutInvalidLevel(t *testing.T) {
+	l := newTestLogger(t)
+	h := LevelHandler(l)
+
+	body := strings.NewReader(`{"level":"not-a-level"}`)
+	req := httptest.NewRequest(http.MethodPut, "/system/log/level", body)
+	rec := httptest.NewRecorder()
+	h(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if got := l.Level(); got != zapcore.InfoLevel {
+		t.Fatalf("logger level = %v, want it unchanged at %v", got, zapcore.InfoLevel)
+	}
+}
+
+func TestLevelHandlerPutMalformedBody(t *testing.T) {
+	l := newTestLogger(t)
+	h := LevelHandler(l)
+
+	req := httptest.NewRequest(http.MethodPut, "/system/log/level", strings.NewReader(`not json`))
+	rec := httptest.NewRecorder()
+	h(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestLevelHandlerMethodNotAllowed(t *testing.T) {
+	l := newTestLogger(t)
+	h := LevelHandler(l)
+
+	req := httptest.NewRequest(http.MethodDelete, "/system/log/level", nil)
+	rec := httptest.NewRecorder()
+	h(rec, req)
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
@@ -1,11 +1,17 @@
 package logger
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"time"
 
+	"github.com/xinliangnote/go-gin-api/pkg/logger/report"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"gopkg.in/natefinch/lumberjack.v2"
@@ -26,8 +32,8 @@ type Option func(*option)
 
 // option结构体中是可选参数，通过设计模式中的函数式选项模式来实现
 type option struct {
-	// 日志级别
-	level zapcore.Level
+	// 日志级别，使用 zap.AtomicLevel 包装，支持运行时动态调整
+	level zap.AtomicLevel
 	// 日志额外输出的K-V
 	fields map[string]string
 	// 写日志的writer
@@ -36,33 +42,53 @@ type option struct {
 	timeLayout string
 	// 是否禁用控制台输出
 	disableConsole bool
+	// loki 配置，非空时会额外增加一路写到 loki 的 core
+	loki *LokiConfig
+	// alert 配置，非空时会额外增加一路镜像写到 IM 报警机器人的 core
+	alert *report.Config
+
+	// sampling 相关配置，samplingTick 非 0 时才会启用采样
+	samplingInitial    int
+	samplingThereafter int
+	samplingTick       time.Duration
+
+	// callerRateLimit 非 0 时，按 (caller, level) 维度做每秒限流
+	callerRateLimit int
 }
 
 // WithDebugLevel only greater than 'level' will output
 func WithDebugLevel() Option {
 	return func(opt *option) {
-		opt.level = zapcore.DebugLevel
+		opt.level.SetLevel(zapcore.DebugLevel)
 	}
 }
 
 // WithInfoLevel only greater than 'level' will output
 func WithInfoLevel() Option {
 	return func(opt *option) {
-		opt.level = zapcore.InfoLevel
+		opt.level.SetLevel(zapcore.InfoLevel)
 	}
 }
 
 // WithWarnLevel only greater than 'level' will output
 func WithWarnLevel() Option {
 	return func(opt *option) {
-		opt.level = zapcore.WarnLevel
+		opt.level.SetLevel(zapcore.WarnLevel)
 	}
 }
 
 // WithErrorLevel only greater than 'level' will output
 func WithErrorLevel() Option {
 	return func(opt *option) {
-		opt.level = zapcore.ErrorLevel
+		opt.level.SetLevel(zapcore.ErrorLevel)
+	}
+}
+
+// WithAtomicLevel 传入一个外部持有的 zap.AtomicLevel，便于多个 logger 共享同一份、
+// 可运行时调整的日志级别（例如 access logger 和 cron logger 想共用一个开关）
+func WithAtomicLevel(level zap.AtomicLevel) Option {
+	return func(opt *option) {
+		opt.level = level
 	}
 }
 
@@ -112,6 +138,14 @@ func WithFileRotationP(file string) Option {
 	}
 }
 
+// WithAlertReporter mirrors log entries at or above cfg.MinLevel to an IM webhook
+// (lark/wecom/telegram), used for online alerting
+func WithAlertReporter(cfg report.Config) Option {
+	return func(opt *option) {
+		opt.alert = &cfg
+	}
+}
+
 // WithTimeLayout custom time format
 func WithTimeLayout(timeLayout string) Option {
 	return func(opt *option) {
@@ -126,10 +160,95 @@ func WithDisableConsole() Option {
 	}
 }
 
+// Logger 在 *zap.Logger 的基础上包了一层，用于承载异步 sink（loki 等）的生命周期管理，
+// 嵌入 *zap.Logger 后，Info/Error/Sync 等原有用法不受影响
+type Logger struct {
+	*zap.Logger
+
+	level       zap.AtomicLevel
+	loki        *lokiSink
+	reporter    *report.Reporter
+	async       *asyncWriter
+	sampled     *uint64
+	rateLimiter *callerLimiter
+}
+
+// Stats 返回当前日志系统的背压/丢弃指标快照，涵盖异步文件 sink、采样和调用处限流
+func (l *Logger) Stats() Stats {
+	var stats Stats
+	if l.async != nil {
+		stats = l.async.Stats()
+	}
+	if l.sampled != nil {
+		stats.LogSampledDroppedTotal = atomic.LoadUint64(l.sampled)
+	}
+	if l.rateLimiter != nil {
+		stats.LogRateLimitedTotal = l.rateLimiter.suppressed()
+	}
+	return stats
+}
+
+// SetLevel 运行时动态调整日志级别，对控制台、文件、loki 这几路 core 同时生效；
+// 报警（alertCore）不受影响，它的上报阈值由 report.Config.MinLevel 独立控制，
+// 不跟着这个 AtomicLevel 走，避免运维调低排查级别时意外把报警阈值也带下去
+func (l *Logger) SetLevel(level zapcore.Level) {
+	l.level.SetLevel(level)
+}
+
+// Level 返回当前生效的日志级别
+func (l *Logger) Level() zapcore.Level {
+	return l.level.Level()
+}
+
+// Flush 把还未来得及推送/上报的日志（loki、IM 报警）同步刷出去，用于进程退出前调用；
+// ctx 给 loki/IM 报警的 HTTP 请求提供一个共享的超时预算，例如 shutdown.Hook 为这个
+// 钩子派生的 timeout，ctx 到期后 Flush 立即返回，不会把调用方一直拖住。
+// loki、IM 报警这两路互不依赖，哪怕一路超时出错，另一路也要照常 flush 完，不能因为
+// 先检查的那一路失败就把后面几路直接跳过——这点跟 shutdown.Hook.Close 聚合多个钩子
+// 错误是同一个道理，所以这里同样把所有子系统的错误 join 到一起再返回
+func (l *Logger) Flush(ctx context.Context) error {
+	var errs []error
+	if l.loki != nil {
+		if err := l.loki.Flush(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("loki: %w", err))
+		}
+	}
+	if l.reporter != nil {
+		if err := l.reporter.Flush(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("report: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close 在 Flush 的基础上，停止 IM 报警、loki、异步文件写入、调用处限流等后台协程，
+// 进程退出前调用一次即可；ctx 同 Flush，约束 loki/IM 报警最后一轮发送的等待时间。
+// 同 Flush，每个子系统都要关掉，一个出错不能连累其它几个被跳过，错误统一 join 返回
+func (l *Logger) Close(ctx context.Context) error {
+	var errs []error
+	if l.async != nil {
+		if err := l.async.close(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("async file: %w", err))
+		}
+	}
+	if l.loki != nil {
+		l.loki.close(ctx)
+	}
+	if l.rateLimiter != nil {
+		l.rateLimiter.stop()
+	}
+	if l.reporter != nil {
+		if err := l.reporter.Close(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("report: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // NewJSONLogger return a json-encoder zap logger,
-func NewJSONLogger(opts ...Option) (*zap.Logger, error) {
+func NewJSONLogger(opts ...Option) (*Logger, error) {
 	// 初始化option结构体，默认日志级别
-	opt := &option{level: DefaultLevel, fields: make(map[string]string)}
+	opt := &option{level: zap.NewAtomicLevelAt(DefaultLevel), fields: make(map[string]string)}
 	for _, f := range opts {
 		f(opt)
 	}
@@ -146,7 +265,7 @@ func NewJSONLogger(opts ...Option) (*zap.Logger, error) {
 		NameKey:       "logger",                      // 被logger.Named(key)使用，可选字段，可默认
 		CallerKey:     "caller",                      // 自定义输出日志中，调用处的key名称
 		MessageKey:    "msg",                         // 自定义输出日志中，错误信息的key名称
-		StacktraceKey: "stacktrace",                  // use by zap.AddStacktrace; optional; useless
+		StacktraceKey: "stacktrace",                  // use by zap.AddStacktrace，下面没有开启，日志不带堆栈
 		LineEnding:    zapcore.DefaultLineEnding,     // 换行符
 		EncodeLevel:   zapcore.LowercaseLevelEncoder, // 对level字段的编码器（大写、小写等）
 		EncodeTime: func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
@@ -161,12 +280,12 @@ func NewJSONLogger(opts ...Option) (*zap.Logger, error) {
 	// 下面两个优先级是为了控台输出时使用的
 	// lowPriority usd by info\debug\warn
 	lowPriority := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-		return lvl >= opt.level && lvl < zapcore.ErrorLevel
+		return opt.level.Enabled(lvl) && lvl < zapcore.ErrorLevel
 	})
 
 	// highPriority usd by error\panic\fatal
 	highPriority := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-		return lvl >= opt.level && lvl >= zapcore.ErrorLevel
+		return opt.level.Enabled(lvl) && lvl >= zapcore.ErrorLevel
 	})
 
 	// stdout and stderr加锁
@@ -198,13 +317,47 @@ func NewJSONLogger(opts ...Option) (*zap.Logger, error) {
 				// 使用AddSync添加文件输出
 				zapcore.AddSync(opt.file),
 				// 如果指定了文件输出，只要级别比定义的高，就写入文件
-				zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-					return lvl >= opt.level
-				}),
+				opt.level,
 			),
 		)
 	}
+	// 如果配置了调用处限流，按 (caller, level) 维度丢弃超过阈值的日志；只作用于控制台/文件这一路，
+	// loki、IM 报警在下面单独 tee 进来，不受限流影响，避免突发报错时报警跟着一起失声
+	var rateLimiter *callerLimiter
+	if opt.callerRateLimit > 0 {
+		core, rateLimiter = wrapCallerRateLimit(core, opt.callerRateLimit)
+	}
+
+	// 如果配置了采样，热点错误路径不会无限制地把日志全部写出去；同样只对控制台/文件生效，
+	// 被采样丢弃的条目不影响下面 loki、IM 报警收到的内容
+	var sampled *uint64
+	if opt.samplingTick > 0 {
+		sampled = new(uint64)
+		core = wrapSampling(core, opt.samplingInitial, opt.samplingThereafter, opt.samplingTick, sampled)
+	}
+
+	// 如果配置了 loki，再加一路写到 loki 的 core；放在采样/限流之后 tee 入，不受它们影响
+	var sink *lokiSink
+	if opt.loki != nil {
+		sink = newLokiSink(*opt.loki, opt.fields)
+		core = zapcore.NewTee(core,
+			newLokiCore(jsonEncoder, opt.level, sink),
+		)
+	}
+
+	// 如果配置了报警，再加一路镜像写到 IM 机器人的 core；同样放在采样/限流之后 tee 入，
+	// 保证热点错误把控制台/文件侧打满、被限流或采样丢弃时，报警依然能收到每一条
+	var reporter *report.Reporter
+	if opt.alert != nil {
+		reporter = report.New(*opt.alert)
+		core = zapcore.NewTee(core, newAlertCore(reporter))
+	}
+
 	// 最终创建logger
+	// 注意：这里故意不开 zap.AddStacktrace，它是 logger 级别的开关，会对控制台/文件/loki
+	// 等所有 core 生效，把每条 Error 及以上的日志都拖上几百字节到几 KB 的堆栈，跟异步写文件、
+	// 采样、调用处限流想控制日志体积的目标直接冲突。IM 报警确实需要堆栈，但只在 alertCore.Write 里
+	// 单独用 takeStacktrace() 现抓一份，不影响其它 core。
 	logger := zap.New(core,
 		zap.AddCaller(),         // 打开caller，可以查看调用函数的文件、行号等信息
 		zap.ErrorOutput(stderr), // 设置错误输出，如果不设置，默认输出到stderr
@@ -214,7 +367,16 @@ func NewJSONLogger(opts ...Option) (*zap.Logger, error) {
 	for key, value := range opt.fields {
 		logger = logger.WithOptions(zap.Fields(zapcore.Field{Key: key, Type: zapcore.StringType, String: value}))
 	}
-	return logger, nil
+	async, _ := opt.file.(*asyncWriter)
+	return &Logger{
+		Logger:      logger,
+		level:       opt.level,
+		loki:        sink,
+		reporter:    reporter,
+		async:       async,
+		sampled:     sampled,
+		rateLimiter: rateLimiter,
+	}, nil
 }
 
 // 下面的meta相关的代码，可以在输出日志时，添加额外的key-value，而不是在定义logger时添加
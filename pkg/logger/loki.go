@@ -0,0 +1,386 @@
+package logger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/xinliangnote/go-gin-api/pkg/env"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// 把日志写到 Grafana Loki，通过 /loki/api/v1/push 接口上报
+
+// LokiConfig loki push 的配置
+type LokiConfig struct {
+	// Endpoint loki 的地址，例如 http://127.0.0.1:3100
+	Endpoint string
+	// Job 对应 loki 的 job 标签
+	Job string
+	// MaxCount 攒够多少条就触发一次 flush
+	MaxCount int
+	// FlushInterval 多久触发一次 flush，MaxCount 和 FlushInterval 任意一个满足即会 flush
+	FlushInterval time.Duration
+	// MaxPendingBatches 环形缓冲区大小，超过后丢弃最老的 batch，避免 loki 不可用时把内存撑爆
+	MaxPendingBatches int
+	// Timeout 单次推送的超时时间
+	Timeout time.Duration
+	// MaxRetry 推送失败（5xx 等非 2xx 响应或网络错误）时的重试次数
+	MaxRetry int
+}
+
+func (c *LokiConfig) withDefaults() {
+	if c.MaxCount <= 0 {
+		c.MaxCount = 100
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = 3 * time.Second
+	}
+	if c.MaxPendingBatches <= 0 {
+		c.MaxPendingBatches = 32
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 5 * time.Second
+	}
+	if c.MaxRetry <= 0 {
+		c.MaxRetry = 3
+	}
+}
+
+// WithLoki 将日志额外写一份到 Loki，可与控制台、文件输出并存
+func WithLoki(cfg LokiConfig) Option {
+	return func(opt *option) {
+		opt.loki = &cfg
+	}
+}
+
+// lokiLine 一条待推送的日志
+type lokiLine struct {
+	ts   int64 // 纳秒时间戳
+	line string
+}
+
+// lokiFlushRequest 一次显式 Flush 调用，携带调用方的 ctx，
+// loop() 处理完会 close(ack) 通知调用方
+type lokiFlushRequest struct {
+	ctx context.Context
+	ack chan struct{}
+}
+
+// lokiSink 负责把日志在内存中攒批，再异步推给 loki，不阻塞业务请求
+type lokiSink struct {
+	cfg    LokiConfig
+	labels map[string]string
+	client *http.Client
+
+	mu  sync.Mutex
+	buf []lokiLine
+
+	// pending 是待发送 batch 组成的环形缓冲区，client 不可用时继续攒批，
+	// 超出 MaxPendingBatches 就丢弃最老的一批，保证内存不会无限增长
+	pending [][]lokiLine
+
+	flushC    chan struct{}         // buf 攒够 MaxCount 时的内部触发信号，不带 ctx
+	flushReqC chan lokiFlushRequest // 显式 Flush(ctx) 调用
+	closeReqC chan context.Context  // 显式 close(ctx) 调用
+	done      chan struct{}         // loop() 退出后关闭，Flush/close 用它判断是否还能等到 ack
+	wg        sync.WaitGroup
+}
+
+func newLokiSink(cfg LokiConfig, staticFields map[string]string) *lokiSink {
+	cfg.withDefaults()
+
+	job := cfg.Job
+	if job == "" {
+		job = "go-gin-api"
+	}
+	labels := map[string]string{
+		"job":    job,
+		"source": "go-gin-api",
+		"env":    env.Active().Value(),
+	}
+	for k, v := range staticFields {
+		labels[k] = v
+	}
+
+	s := &lokiSink{
+		cfg:       cfg,
+		labels:    labels,
+		client:    &http.Client{Timeout: cfg.Timeout},
+		flushC:    make(chan struct{}, 1),
+		flushReqC: make(chan lokiFlushRequest),
+		closeReqC: make(chan context.Context, 1),
+		done:      make(chan struct{}),
+		pending:   make([][]lokiLine, 0, cfg.MaxPendingBatches),
+	}
+
+	s.wg.Add(1)
+	go s.loop()
+
+	return s
+}
+
+func (s *lokiSink) push(ts time.Time, line string) {
+	s.mu.Lock()
+	s.buf = append(s.buf, lokiLine{ts: ts.UnixNano(), line: line})
+	full := len(s.buf) >= s.cfg.MaxCount
+	s.mu.Unlock()
+
+	if full {
+		select {
+		case s.flushC <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *lokiSink) loop() {
+	defer s.wg.Done()
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.drainAndSend(context.Background())
+		case <-s.flushC:
+			s.drainAndSend(context.Background())
+		case req := <-s.flushReqC:
+			s.drainAndSend(req.ctx)
+			close(req.ack)
+		case ctx := <-s.closeReqC:
+			s.drainAndSend(ctx)
+			return
+		}
+	}
+}
+
+// drainAndSend 把当前缓冲区里的日志取出来，入队到 pending，再尝试发送；ctx 取消后
+// 立刻停止发送剩下的 pending batch，留给下一轮处理，而不是把调用方一直拖住
+func (s *lokiSink) drainAndSend(ctx context.Context) {
+	s.mu.Lock()
+	if len(s.buf) == 0 {
+		s.mu.Unlock()
+	} else {
+		batch := s.buf
+		s.buf = nil
+		s.mu.Unlock()
+
+		s.enqueue(batch)
+	}
+
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+		batch, ok := s.dequeue()
+		if !ok {
+			return
+		}
+		if err := s.sendWithRetry(ctx, batch); err != nil {
+			// 多次重试仍失败，放回队首，等下一轮再试，避免无限重试阻塞 flush 循环
+			s.requeueFront(batch)
+			return
+		}
+	}
+}
+
+func (s *lokiSink) enqueue(batch []lokiLine) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.pending) >= s.cfg.MaxPendingBatches {
+		// 丢弃最老的一批，保证内存有界
+		s.pending = s.pending[1:]
+	}
+	s.pending = append(s.pending, batch)
+}
+
+func (s *lokiSink) dequeue() ([]lokiLine, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.pending) == 0 {
+		return nil, false
+	}
+	batch := s.pending[0]
+	s.pending = s.pending[1:]
+	return batch, true
+}
+
+func (s *lokiSink) requeueFront(batch []lokiLine) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending = append([][]lokiLine{batch}, s.pending...)
+	if len(s.pending) > s.cfg.MaxPendingBatches {
+		s.pending = s.pending[:s.cfg.MaxPendingBatches]
+	}
+}
+
+func (s *lokiSink) sendWithRetry(ctx context.Context, batch []lokiLine) error {
+	body, err := s.encode(batch)
+	if err != nil {
+		return err
+	}
+
+	backoff := 200 * time.Millisecond
+	var lastErr error
+	for i := 0; i <= s.cfg.MaxRetry; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			backoff *= 2
+		}
+
+		lastErr = s.post(ctx, body)
+		if lastErr == nil {
+			return nil
+		}
+	}
+	return lastErr
+}
+
+func (s *lokiSink) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.Endpoint+"/loki/api/v1/push", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("loki push returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *lokiSink) encode(batch []lokiLine) ([]byte, error) {
+	values := make([][2]string, 0, len(batch))
+	for _, l := range batch {
+		values = append(values, [2]string{strconv.FormatInt(l.ts, 10), l.line})
+	}
+
+	payload := struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string        `json:"values"`
+		} `json:"streams"`
+	}{}
+	payload.Streams = append(payload.Streams, struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string        `json:"values"`
+	}{
+		Stream: s.labels,
+		Values: values,
+	})
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	if _, err := zw.Write(raw); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return gz.Bytes(), nil
+}
+
+// Flush 同步地把当前缓冲区和待发送队列中的日志依次推送出去；ctx 取消后立即返回，
+// 未发完的部分留给下一轮 flush，不会无限期阻塞调用方。某一批重试用尽仍失败时，
+// 这一批和其后的部分同样留给下一轮，此时 Flush 仍返回 nil
+func (s *lokiSink) Flush(ctx context.Context) error {
+	req := lokiFlushRequest{ctx: ctx, ack: make(chan struct{})}
+	select {
+	case s.flushReqC <- req:
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-req.ack:
+	case <-s.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
+// close 让 loop() 用 ctx 做最后一次 drainAndSend 之后退出，ctx 取消后不再等剩下的发完
+func (s *lokiSink) close(ctx context.Context) {
+	select {
+	case s.closeReqC <- ctx:
+	default:
+	}
+	s.wg.Wait()
+}
+
+// lokiCore 是写到 loki 的 zapcore.Core 实现
+type lokiCore struct {
+	zapcore.LevelEnabler
+	enc  zapcore.Encoder
+	sink *lokiSink
+}
+
+func newLokiCore(enc zapcore.Encoder, enabler zapcore.LevelEnabler, sink *lokiSink) zapcore.Core {
+	return &lokiCore{LevelEnabler: enabler, enc: enc, sink: sink}
+}
+
+func (c *lokiCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := c.enc.Clone()
+	for _, f := range fields {
+		f.AddTo(clone)
+	}
+	return &lokiCore{LevelEnabler: c.LevelEnabler, enc: clone, sink: c.sink}
+}
+
+func (c *lokiCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *lokiCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	line := buf.String()
+	buf.Free()
+
+	c.sink.push(ent.Time, line)
+	return nil
+}
+
+func (c *lokiCore) Sync() error {
+	// zapcore.Core.Sync() 没有 ctx 参数，这里没有调用方可以传入的 deadline，
+	// 用 Background；真正有超时预算的退出路径走 Logger.Close(ctx)
+	return c.sink.Flush(context.Background())
+}
@@ -0,0 +1,87 @@
+package logger
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLokiSinkEnqueueDropsOldestPendingBatch(t *testing.T) {
+	cfg := LokiConfig{Endpoint: "http://unused", MaxPendingBatches: 2}
+	cfg.withDefaults()
+	s := &lokiSink{cfg: cfg, pending: make([][]lokiLine, 0, cfg.MaxPendingBatches)}
+
+	s.enqueue([]lokiLine{{line: "1"}})
+	s.enqueue([]lokiLine{{line: "2"}})
+	s.enqueue([]lokiLine{{line: "3"}}) // 超过 MaxPendingBatches=2，应该丢掉最老的 "1"
+
+	if got := len(s.pending); got != 2 {
+		t.Fatalf("expected pending to stay capped at 2, got %d", got)
+	}
+	if got := s.pending[0][0].line; got != "2" {
+		t.Fatalf("expected oldest batch to be dropped, pending[0] = %q, want %q", got, "2")
+	}
+}
+
+func TestLokiSinkRequeueFrontRespectsCap(t *testing.T) {
+	cfg := LokiConfig{Endpoint: "http://unused", MaxPendingBatches: 1}
+	cfg.withDefaults()
+	s := &lokiSink{cfg: cfg, pending: [][]lokiLine{{{line: "old"}}}}
+
+	s.requeueFront([]lokiLine{{line: "new"}})
+
+	if got := len(s.pending); got != 1 {
+		t.Fatalf("expected pending to stay capped at 1 after requeue, got %d", got)
+	}
+	if got := s.pending[0][0].line; got != "new" {
+		t.Fatalf("requeueFront should put the batch back at the front, pending[0] = %q", got)
+	}
+}
+
+func TestLokiSinkSendWithRetryRetriesOn5xxThenSucceeds(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cfg := LokiConfig{Endpoint: srv.URL, MaxRetry: 5, Timeout: time.Second}
+	cfg.withDefaults()
+	s := &lokiSink{cfg: cfg, client: srv.Client(), labels: map[string]string{"job": "test"}}
+
+	err := s.sendWithRetry(context.Background(), []lokiLine{{ts: 1, line: "hello"}})
+	if err != nil {
+		t.Fatalf("sendWithRetry() error = %v, want nil after eventually succeeding", err)
+	}
+	if got := atomic.LoadInt32(&attempts); got != 3 {
+		t.Fatalf("expected exactly 3 attempts (2 failures + 1 success), got %d", got)
+	}
+}
+
+func TestLokiSinkSendWithRetryGivesUpAfterMaxRetry(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	cfg := LokiConfig{Endpoint: srv.URL, MaxRetry: 2, Timeout: time.Second}
+	cfg.withDefaults()
+	s := &lokiSink{cfg: cfg, client: srv.Client(), labels: map[string]string{"job": "test"}}
+
+	err := s.sendWithRetry(context.Background(), []lokiLine{{ts: 1, line: "hello"}})
+	if err == nil {
+		t.Fatal("sendWithRetry() error = nil, want an error after exhausting retries")
+	}
+	if got, want := atomic.LoadInt32(&attempts), int32(3); got != want { // 首次 + MaxRetry 次重试
+		t.Fatalf("expected %d attempts, got %d", want, got)
+	}
+}
@@ -0,0 +1,351 @@
+// Package report 把日志条目转发到 IM 机器人（飞书 / 企业微信 / Telegram），
+// 用于线上报警，不依赖 pkg/logger 内部结构，可以被其他地方单独复用
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Type 支持的 IM 渠道
+type Type string
+
+const (
+	// TypeLark 飞书群机器人
+	TypeLark Type = "lark"
+	// TypeWecom 企业微信群机器人
+	TypeWecom Type = "wecom"
+	// TypeTelegram telegram bot
+	TypeTelegram Type = "telegram"
+)
+
+// Config 报警上报的配置
+type Config struct {
+	// Type 渠道类型：lark / wecom / telegram
+	Type Type
+	// Token 群机器人的 webhook token（telegram 时是 bot token）
+	Token string
+	// ChatID telegram 专用，群/频道 id
+	ChatID string
+	// MinLevel 达到该级别才上报，默认 zapcore.ErrorLevel
+	MinLevel zapcore.Level
+	// QueueSize 缓冲队列长度，满了之后新条目会被丢弃，避免阻塞业务请求
+	QueueSize int
+	// MaxCount 攒够多少条触发一次上报
+	MaxCount int
+	// FlushSec 多少秒触发一次上报，MaxCount 和 FlushSec 任意一个满足即触发
+	FlushSec int
+	// Timeout 单次 HTTP 请求的超时时间
+	Timeout time.Duration
+	// MaxFlushBatch Flush()/Close() 时一次最多从队列里取多少条去重后发送；
+	// 超出的部分留在队列里等下一轮，避免进程退出前队列里挤满大量不同消息，
+	// 顺序发送把 Close() 拖住很久
+	MaxFlushBatch int
+}
+
+func (c *Config) withDefaults() {
+	if c.MinLevel == 0 {
+		c.MinLevel = zapcore.ErrorLevel
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = 1000
+	}
+	if c.MaxCount <= 0 {
+		c.MaxCount = 20
+	}
+	if c.FlushSec <= 0 {
+		c.FlushSec = 10
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 5 * time.Second
+	}
+	if c.MaxFlushBatch <= 0 {
+		c.MaxFlushBatch = 50
+	}
+}
+
+// Entry 一条待上报的日志
+type Entry struct {
+	Level      zapcore.Level
+	Msg        string
+	Caller     string
+	Stacktrace string
+	Fields     map[string]interface{}
+}
+
+// dedupeKey 判断两条日志是否“相同”的依据：level + msg + caller
+func (e Entry) dedupeKey() string {
+	return fmt.Sprintf("%s|%s|%s", e.Level, e.Msg, e.Caller)
+}
+
+// flushRequest 一次显式 Flush 调用，携带调用方的 ctx，
+// loop() 处理完会 close(ack) 通知调用方
+type flushRequest struct {
+	ctx context.Context
+	ack chan struct{}
+}
+
+// Reporter 聚合日志条目并按批上报到 IM webhook，内部是一个有界队列 + 后台 flush 协程
+type Reporter struct {
+	cfg    Config
+	client *http.Client
+
+	queue     chan Entry
+	flushC    chan flushRequest
+	closeReqC chan context.Context // 显式 Close(ctx) 调用
+	done      chan struct{}        // loop() 退出后关闭，Flush/Close 用它判断是否还能等到 ack
+	wg        sync.WaitGroup
+
+	// webhookURLOverride 仅供测试使用，跳过 webhookURL() 里 lark/wecom/telegram 的真实域名，
+	// 让单测能把请求指向一个本地 httptest server
+	webhookURLOverride string
+}
+
+// New 创建一个 Reporter 并启动后台上报协程
+func New(cfg Config) *Reporter {
+	cfg.withDefaults()
+
+	r := &Reporter{
+		cfg:       cfg,
+		client:    &http.Client{Timeout: cfg.Timeout},
+		queue:     make(chan Entry, cfg.QueueSize),
+		flushC:    make(chan flushRequest),
+		closeReqC: make(chan context.Context, 1),
+		done:      make(chan struct{}),
+	}
+
+	r.wg.Add(1)
+	go r.loop()
+
+	return r
+}
+
+// Enabled 判断某个级别是否需要上报
+func (r *Reporter) Enabled(level zapcore.Level) bool {
+	return level >= r.cfg.MinLevel
+}
+
+// Push 非阻塞地把一条日志放入待上报队列，队列满时直接丢弃，保证不拖慢请求处理
+func (r *Reporter) Push(e Entry) {
+	select {
+	case r.queue <- e:
+	default:
+	}
+}
+
+func (r *Reporter) loop() {
+	defer r.wg.Done()
+	defer close(r.done)
+
+	ticker := time.NewTicker(time.Duration(r.cfg.FlushSec) * time.Second)
+	defer ticker.Stop()
+
+	batch := make([]Entry, 0, r.cfg.MaxCount)
+	for {
+		select {
+		case e := <-r.queue:
+			batch = append(batch, e)
+			if len(batch) >= r.cfg.MaxCount {
+				r.sendWithBudget(context.Background(), batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				r.sendWithBudget(context.Background(), batch)
+				batch = batch[:0]
+			}
+		case req := <-r.flushC:
+			batch = r.drainQueue(batch, r.cfg.MaxFlushBatch)
+			if len(batch) > 0 {
+				r.sendWithBudget(req.ctx, batch)
+				batch = batch[:0]
+			}
+			close(req.ack)
+		case ctx := <-r.closeReqC:
+			// 队列里剩下的条目如果超过 MaxFlushBatch 会被直接丢弃：
+			// 宁可丢日志也不让进程退出被顺序发送拖住
+			batch = r.drainQueue(batch, r.cfg.MaxFlushBatch)
+			if len(batch) > 0 {
+				r.sendWithBudget(ctx, batch)
+			}
+			return
+		}
+	}
+}
+
+// drainQueue 从队列里最多再取 limit - len(batch) 条追加到 batch，limit <= 0 时不设上限
+func (r *Reporter) drainQueue(batch []Entry, limit int) []Entry {
+	for limit <= 0 || len(batch) < limit {
+		select {
+		case e := <-r.queue:
+			batch = append(batch, e)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+// dedupe 把相同 (level, msg, caller) 的条目合并成一条，并附加 xN 的次数后缀，避免刷屏
+func dedupe(batch []Entry) []Entry {
+	order := make([]string, 0, len(batch))
+	counts := make(map[string]int, len(batch))
+	first := make(map[string]Entry, len(batch))
+
+	for _, e := range batch {
+		key := e.dedupeKey()
+		if _, ok := first[key]; !ok {
+			first[key] = e
+			order = append(order, key)
+		}
+		counts[key]++
+	}
+
+	out := make([]Entry, 0, len(order))
+	for _, key := range order {
+		e := first[key]
+		if n := counts[key]; n > 1 {
+			e.Msg = fmt.Sprintf("%s x%d", e.Msg, n)
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// sendWithBudget 给整批 dedupe 后的发送分配一个跟 batch 大小挂钩的共享 deadline，
+// 派生自调用方传入的 parent（例如 shutdown.Hook 给的超时），避免一批里挤了很多条
+// 不同的消息时，顺序发送一条条调 Timeout 累加起来把 parent 的预算撑爆
+func (r *Reporter) sendWithBudget(parent context.Context, batch []Entry) {
+	ctx, cancel := context.WithTimeout(parent, r.cfg.Timeout*time.Duration(len(batch)))
+	defer cancel()
+	r.send(ctx, batch)
+}
+
+func (r *Reporter) send(ctx context.Context, batch []Entry) {
+	for _, e := range dedupe(batch) {
+		if ctx.Err() != nil {
+			// 共享 deadline 已经到了，剩下的条目这一轮不再发，留给下一轮
+			return
+		}
+		body, err := r.format(e)
+		if err != nil {
+			continue
+		}
+		if err := r.post(ctx, body); err != nil {
+			// report 包不依赖 pkg/logger，这里没有 zap.Logger 可用；webhook 配错（token/chat id
+			// 失效、返回非 2xx）不能悄无声息地吞掉，否则运维永远不知道报警已经哑了，走 stderr
+			// 兜底，跟 main.go 里最后一行 shutdown 错误的处理是同一个思路
+			fmt.Fprintf(os.Stderr, "report: webhook post failed: %v\n", err)
+		}
+	}
+}
+
+func (r *Reporter) format(e Entry) ([]byte, error) {
+	text := fmt.Sprintf("[%s] %s\ncaller: %s", e.Level.CapitalString(), e.Msg, e.Caller)
+	if e.Stacktrace != "" {
+		text += fmt.Sprintf("\nstacktrace:\n%s", e.Stacktrace)
+	}
+	for k, v := range e.Fields {
+		text += fmt.Sprintf("\n%s: %v", k, v)
+	}
+
+	switch r.cfg.Type {
+	case TypeLark:
+		return json.Marshal(map[string]interface{}{
+			"msg_type": "text",
+			"content":  map[string]string{"text": text},
+		})
+	case TypeWecom:
+		return json.Marshal(map[string]interface{}{
+			"msgtype": "markdown",
+			"markdown": map[string]string{
+				"content": text,
+			},
+		})
+	case TypeTelegram:
+		return json.Marshal(map[string]interface{}{
+			"chat_id": r.cfg.ChatID,
+			"text":    text,
+		})
+	default:
+		return nil, fmt.Errorf("report: unsupported type %q", r.cfg.Type)
+	}
+}
+
+func (r *Reporter) webhookURL() string {
+	if r.webhookURLOverride != "" {
+		return r.webhookURLOverride
+	}
+	switch r.cfg.Type {
+	case TypeLark:
+		return "https://open.feishu.cn/open-apis/bot/v2/hook/" + r.cfg.Token
+	case TypeWecom:
+		return "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=" + r.cfg.Token
+	case TypeTelegram:
+		return "https://api.telegram.org/bot" + r.cfg.Token + "/sendMessage"
+	default:
+		return ""
+	}
+}
+
+func (r *Reporter) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.webhookURL(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("report: webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Flush 同步地把当前队列中的日志上报出去（一次最多 MaxFlushBatch 条，其余留在队列里等下一轮），
+// 直到后台协程把这一批真正发送完才返回；
+// Close() 之后调用也是安全的，loop() 已经退出时会直接返回，而不会永久阻塞。
+// ctx 取消后 Flush 立即返回 ctx.Err()，未发完的部分留给下一轮
+func (r *Reporter) Flush(ctx context.Context) error {
+	req := flushRequest{ctx: ctx, ack: make(chan struct{})}
+	select {
+	case r.flushC <- req:
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-req.ack:
+	case <-r.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
+// Close 停止后台协程之前，先在 ctx 的预算内把队列中剩余的日志上报出去；
+// 最多发送 MaxFlushBatch 条，超出的部分直接丢弃，不会上报
+func (r *Reporter) Close(ctx context.Context) error {
+	select {
+	case r.closeReqC <- ctx:
+	default:
+		// 已经关闭过，无需重复触发
+	}
+	r.wg.Wait()
+	return nil
+}
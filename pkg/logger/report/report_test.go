@@ -0,0 +1,73 @@
+package report
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestDedupe(t *testing.T) {
+	batch := []Entry{
+		{Level: zapcore.ErrorLevel, Msg: "boom", Caller: "foo.go:10"},
+		{Level: zapcore.ErrorLevel, Msg: "boom", Caller: "foo.go:10"},
+		{Level: zapcore.ErrorLevel, Msg: "boom", Caller: "foo.go:10"},
+		{Level: zapcore.WarnLevel, Msg: "slow query", Caller: "bar.go:20"},
+	}
+
+	out := dedupe(batch)
+	if len(out) != 2 {
+		t.Fatalf("expected 2 deduped entries, got %d: %+v", len(out), out)
+	}
+
+	if got, want := out[0].Msg, "boom x3"; got != want {
+		t.Errorf("repeated entry Msg = %q, want %q", got, want)
+	}
+	if got, want := out[1].Msg, "slow query"; got != want {
+		t.Errorf("non-repeated entry Msg = %q, want %q (should not gain a count suffix)", got, want)
+	}
+}
+
+func TestDedupePreservesFirstSeenOrder(t *testing.T) {
+	batch := []Entry{
+		{Level: zapcore.ErrorLevel, Msg: "b", Caller: "b.go:1"},
+		{Level: zapcore.ErrorLevel, Msg: "a", Caller: "a.go:1"},
+		{Level: zapcore.ErrorLevel, Msg: "b", Caller: "b.go:1"},
+	}
+
+	out := dedupe(batch)
+	if len(out) != 2 {
+		t.Fatalf("expected 2 deduped entries, got %d", len(out))
+	}
+	if out[0].Msg != "b x2" || out[1].Msg != "a" {
+		t.Fatalf("dedupe should keep first-seen order, got %+v", out)
+	}
+}
+
+func TestReporterPostTreatsNon2xxAsFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized) // 模拟 token 失效
+	}))
+	defer srv.Close()
+
+	r := &Reporter{cfg: Config{Type: TypeLark, Token: "unused"}, client: srv.Client(), webhookURLOverride: srv.URL}
+
+	if err := r.post(context.Background(), []byte(`{}`)); err == nil {
+		t.Fatal("post() error = nil, want an error for a 401 response")
+	}
+}
+
+func TestReporterPostSucceedsOn2xx(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	r := &Reporter{cfg: Config{Type: TypeLark, Token: "unused"}, client: srv.Client(), webhookURLOverride: srv.URL}
+
+	if err := r.post(context.Background(), []byte(`{}`)); err != nil {
+		t.Fatalf("post() error = %v, want nil for a 204 response", err)
+	}
+}
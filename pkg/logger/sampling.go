@@ -0,0 +1,196 @@
+package logger
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// WithSampling 启用 zap 内置的采样策略：每个 tick 窗口内，同一条（message 完全相同）日志
+// 前 initial 条全部输出，之后每 thereafter 条才输出 1 条，避免热点错误路径短时间内打爆磁盘
+func WithSampling(initial, thereafter int, tick time.Duration) Option {
+	return func(opt *option) {
+		opt.samplingInitial = initial
+		opt.samplingThereafter = thereafter
+		opt.samplingTick = tick
+	}
+}
+
+// WithCallerRateLimit 按 (调用处 file:line, level) 维度做限流，超过 perCallerPerSec 的部分
+// 直接丢弃，并在窗口结束时补一条 "suppressed N identical logs from foo.go:88" 的汇总日志
+func WithCallerRateLimit(perCallerPerSec int) Option {
+	return func(opt *option) {
+		opt.callerRateLimit = perCallerPerSec
+	}
+}
+
+// wrapSampling 给 core 套上 zap 内置的采样器，并通过 SamplerHook 统计被丢弃的条数
+func wrapSampling(core zapcore.Core, initial, thereafter int, tick time.Duration, dropped *uint64) zapcore.Core {
+	return zapcore.NewSamplerWithOptions(core, tick, initial, thereafter,
+		zapcore.SamplerHook(func(ent zapcore.Entry, decision zapcore.SamplingDecision) {
+			if decision&zapcore.LogDropped != 0 {
+				atomic.AddUint64(dropped, 1)
+			}
+		}),
+	)
+}
+
+// callerLimiter 按 (caller, level) 维度统计 1 秒窗口内的调用次数，超过阈值的条目被丢弃，
+// 窗口结束时把丢弃数汇总成一条日志补发出去。窗口是固定窗口而不是滑动窗口：以某个 key
+// 第一次出现的时间为起点，满 1 秒后下一条日志开启新窗口并把计数清零
+type callerLimiter struct {
+	perSec int
+	owner  zapcore.Core // 汇总日志最终通过它写出去
+
+	mu      sync.Mutex
+	windows map[string]*rlWindow
+	// completed 是被 allow() 提前滚动掉的窗口，等着 flushExpired 把它们的 suppressed
+	// 汇总写出去；不这样做的话，持续高频的调用处每次 allow() 都会抢在 1s ticker 之前
+	// 把旧窗口整个替换掉，summary 永远没机会被 flushExpired 观察到就被覆盖丢弃了
+	completed []rlWindow
+
+	suppressedTotal uint64
+
+	closeC chan struct{}
+	wg     sync.WaitGroup
+}
+
+type rlWindow struct {
+	caller      string
+	level       zapcore.Level
+	windowStart time.Time
+	count       int
+	suppressed  int
+}
+
+func newCallerLimiter(perSec int, owner zapcore.Core) *callerLimiter {
+	l := &callerLimiter{
+		perSec:  perSec,
+		owner:   owner,
+		windows: make(map[string]*rlWindow),
+		closeC:  make(chan struct{}),
+	}
+
+	l.wg.Add(1)
+	go l.loop()
+
+	return l
+}
+
+func (l *callerLimiter) key(ent zapcore.Entry) string {
+	return fmt.Sprintf("%s|%s", ent.Caller.String(), ent.Level)
+}
+
+// allow 返回 false 表示这条日志应该被丢弃
+func (l *callerLimiter) allow(ent zapcore.Entry) bool {
+	now := time.Now()
+	key := l.key(ent)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.windowStart) >= time.Second {
+		if ok && w.suppressed > 0 {
+			// 旧窗口马上要被替换掉了，在丢失引用之前先把它交给 flushExpired 汇总发出去
+			l.completed = append(l.completed, *w)
+		}
+		w = &rlWindow{caller: ent.Caller.String(), level: ent.Level, windowStart: now}
+		l.windows[key] = w
+	}
+
+	w.count++
+	if w.count > l.perSec {
+		w.suppressed++
+		atomic.AddUint64(&l.suppressedTotal, 1)
+		return false
+	}
+	return true
+}
+
+// loop 每秒检查一次所有窗口，把已经过期且有丢弃记录的窗口汇总成一条日志写出去
+func (l *callerLimiter) loop() {
+	defer l.wg.Done()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.flushExpired()
+		case <-l.closeC:
+			return
+		}
+	}
+}
+
+// stop 停止后台协程，进程退出前调用一次即可
+func (l *callerLimiter) stop() {
+	close(l.closeC)
+	l.wg.Wait()
+}
+
+func (l *callerLimiter) flushExpired() {
+	now := time.Now()
+
+	l.mu.Lock()
+	// 先取走 allow() 滚动窗口时交接过来的那些，它们已经不在 l.windows 里了
+	summaries := l.completed
+	l.completed = nil
+	for key, w := range l.windows {
+		if now.Sub(w.windowStart) < time.Second {
+			continue
+		}
+		if w.suppressed > 0 {
+			summaries = append(summaries, *w)
+		}
+		delete(l.windows, key)
+	}
+	l.mu.Unlock()
+
+	for _, w := range summaries {
+		ent := zapcore.Entry{
+			Level:   w.level,
+			Time:    now,
+			Message: fmt.Sprintf("suppressed %d identical logs from %s", w.suppressed, w.caller),
+		}
+		// 走 Check 而不是直接 Write，让汇总日志也遵守 owner 的级别路由，
+		// 避免无条件写进 owner 下面的每一路 core（比如重复打到 stdout 和 stderr）
+		if ce := l.owner.Check(ent, nil); ce != nil {
+			ce.Write()
+		}
+	}
+}
+
+func (l *callerLimiter) suppressed() uint64 {
+	return atomic.LoadUint64(&l.suppressedTotal)
+}
+
+// callerRateLimitCore 是给 core 套上 callerLimiter 限流的装饰器
+type callerRateLimitCore struct {
+	zapcore.Core
+	limiter *callerLimiter
+}
+
+func wrapCallerRateLimit(core zapcore.Core, perCallerPerSec int) (zapcore.Core, *callerLimiter) {
+	limiter := newCallerLimiter(perCallerPerSec, core)
+	return &callerRateLimitCore{Core: core, limiter: limiter}, limiter
+}
+
+func (c *callerRateLimitCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(ent.Level) {
+		return ce
+	}
+	if !c.limiter.allow(ent) {
+		return ce
+	}
+	return ce.AddCore(ent, c)
+}
+
+func (c *callerRateLimitCore) With(fields []zapcore.Field) zapcore.Core {
+	return &callerRateLimitCore{Core: c.Core.With(fields), limiter: c.limiter}
+}
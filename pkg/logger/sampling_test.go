@@ -0,0 +1,114 @@
+package logger
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// recordingCore 只记录收到的 entry，方便断言 flushExpired() 写出的汇总日志
+type recordingCore struct {
+	zapcore.LevelEnabler
+	entries []zapcore.Entry
+}
+
+func (c *recordingCore) With(fields []zapcore.Field) zapcore.Core { return c }
+
+func (c *recordingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *recordingCore) Write(ent zapcore.Entry, _ []zapcore.Field) error {
+	c.entries = append(c.entries, ent)
+	return nil
+}
+
+func (c *recordingCore) Sync() error { return nil }
+
+func TestCallerLimiterAllowSuppressesOverflow(t *testing.T) {
+	owner := &recordingCore{LevelEnabler: zapcore.InfoLevel}
+	l := newCallerLimiter(2, owner)
+	defer l.stop()
+
+	ent := zapcore.Entry{Caller: zapcore.NewEntryCaller(0, "foo.go", 88, true), Level: zapcore.ErrorLevel}
+
+	allowed := 0
+	for i := 0; i < 5; i++ {
+		if l.allow(ent) {
+			allowed++
+		}
+	}
+
+	if allowed != 2 {
+		t.Fatalf("expected first 2 calls within the window to be allowed, got %d", allowed)
+	}
+	if got := l.suppressed(); got != 3 {
+		t.Fatalf("expected 3 suppressed entries, got %d", got)
+	}
+}
+
+func TestCallerLimiterFlushExpiredEmitsSummary(t *testing.T) {
+	owner := &recordingCore{LevelEnabler: zapcore.InfoLevel}
+	l := newCallerLimiter(1, owner)
+	defer l.stop()
+
+	ent := zapcore.Entry{Caller: zapcore.NewEntryCaller(0, "foo.go", 88, true), Level: zapcore.ErrorLevel}
+	l.allow(ent)
+	l.allow(ent) // 超过 perSec=1，计入 suppressed
+
+	// 手动把窗口起点拨回一秒以前，模拟窗口已过期，而不用真的 time.Sleep(time.Second)
+	l.mu.Lock()
+	for _, w := range l.windows {
+		w.windowStart = time.Now().Add(-2 * time.Second)
+	}
+	l.mu.Unlock()
+
+	l.flushExpired()
+
+	if len(owner.entries) != 1 {
+		t.Fatalf("expected exactly 1 summary entry written to owner, got %d", len(owner.entries))
+	}
+	want := "suppressed 1 identical logs from foo.go:88"
+	if got := owner.entries[0].Message; got != want {
+		t.Fatalf("summary message = %q, want %q", got, want)
+	}
+}
+
+// TestCallerLimiterAllowHandsOffRolledOverWindow 重现持续高频调用场景：每个窗口都在
+// ticker 的 1s tick 之前就被下一次 allow() 看到已过期并替换掉。旧的修复方式只靠 ticker
+// 去“发现”过期窗口，这种场景下 ticker 永远抢不过紧随其后的下一次 allow() 调用，汇总行
+// 就会跟着被覆盖的窗口一起消失。
+func TestCallerLimiterAllowHandsOffRolledOverWindow(t *testing.T) {
+	owner := &recordingCore{LevelEnabler: zapcore.InfoLevel}
+	l := newCallerLimiter(1, owner)
+	defer l.stop()
+
+	ent := zapcore.Entry{Caller: zapcore.NewEntryCaller(0, "foo.go", 88, true), Level: zapcore.ErrorLevel}
+
+	const rollovers = 3
+	for i := 0; i < rollovers; i++ {
+		l.allow(ent) // 本窗口内第 1 条，放行
+		l.allow(ent) // 第 2 条，超过 perSec=1，计入 suppressed
+
+		// 模拟窗口过期，但不调用 flushExpired()：紧接着的下一次 allow() 应该自己把这个
+		// 窗口存进 l.completed，而不是指望还没跑到的 ticker 去发现它
+		l.mu.Lock()
+		for _, w := range l.windows {
+			w.windowStart = time.Now().Add(-2 * time.Second)
+		}
+		l.mu.Unlock()
+	}
+
+	l.flushExpired()
+
+	if got := len(owner.entries); got != rollovers {
+		t.Fatalf("expected %d summary lines (one per rolled-over window), got %d", rollovers, got)
+	}
+	if got := l.suppressed(); got != uint64(rollovers) {
+		t.Fatalf("expected %d suppressed entries total, got %d", rollovers, got)
+	}
+}
@@ -1,9 +1,16 @@
 package shutdown
 
 import (
+	"context"
+	"fmt"
+	"log"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 )
 
 // 这里为了确保hook实现了Hook接口，在编译时检查这种实现是否正确，提高代码的可维护性和健壮性。
@@ -14,41 +21,128 @@ type Hook interface {
 	// WithSignals add more signals into hook
 	WithSignals(signals ...syscall.Signal) Hook
 
-	// Close register shutdown handles
-	Close(funcs ...func())
+	// Register 注册一个关闭钩子，priority 越小越先执行（例如先让 http server 停止接收新请求，
+	// 再关闭 db/cache），timeout 是这个钩子单独拥有的超时时间，超过后 ctx 会被取消
+	Register(name string, priority int, timeout time.Duration, fn func(ctx context.Context) error) Hook
+
+	// Signal 返回一个在收到退出信号后会被关闭的 channel，供不需要注册关闭钩子、
+	// 只想感知退出事件的长驻组件使用（例如 cron、kafka consumer 的主循环）
+	Signal() <-chan struct{}
+
+	// Close 阻塞直到收到退出信号，然后按 priority 升序依次执行所有已注册的钩子。
+	// ctx 是整个关闭流程的总超时时间，每个钩子还会在此基础上派生自己的 timeout
+	Close(ctx context.Context) error
+}
+
+type namedHook struct {
+	name     string
+	priority int
+	timeout  time.Duration
+	fn       func(ctx context.Context) error
 }
 
 type hook struct {
-	ctx chan os.Signal
+	sigCh chan os.Signal
+
+	signalCh   chan struct{}
+	signalOnce sync.Once
+
+	mu    sync.Mutex
+	hooks []namedHook
 }
 
 // NewHook create a Hook instance
 func NewHook() Hook {
-	hook := &hook{
-		ctx: make(chan os.Signal, 1),
+	h := &hook{
+		sigCh:    make(chan os.Signal, 1),
+		signalCh: make(chan struct{}),
 	}
 	// 监听信号
-	return hook.WithSignals(syscall.SIGINT, syscall.SIGTERM)
+	return h.WithSignals(syscall.SIGINT, syscall.SIGTERM)
 }
 
 func (h *hook) WithSignals(signals ...syscall.Signal) Hook {
 	// 监听信号，如果有信号传入，就会往ctx中写入数据
 	for _, s := range signals {
-		signal.Notify(h.ctx, s)
+		signal.Notify(h.sigCh, s)
 	}
 
 	return h
 }
 
-func (h *hook) Close(funcs ...func()) {
-	// 从ctx中读取数据，如果没有数据，就会阻塞，读取到数据则说明有信号传入，就会执行funcs中的函数
-	select {
-	case <-h.ctx:
-	}
+func (h *hook) Register(name string, priority int, timeout time.Duration, fn func(ctx context.Context) error) Hook {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.hooks = append(h.hooks, namedHook{name: name, priority: priority, timeout: timeout, fn: fn})
+
+	return h
+}
+
+func (h *hook) Signal() <-chan struct{} {
+	return h.signalCh
+}
+
+func (h *hook) Close(ctx context.Context) error {
+	// 从sigCh中读取信号，如果没有数据，就会阻塞，读取到数据则说明有信号传入
+	<-h.sigCh
 	// 关闭监听
-	signal.Stop(h.ctx)
+	signal.Stop(h.sigCh)
+	// 唤醒所有只关心“要关闭了”这件事、没有注册关闭钩子的组件
+	h.signalOnce.Do(func() { close(h.signalCh) })
+
+	h.mu.Lock()
+	hooks := make([]namedHook, len(h.hooks))
+	copy(hooks, h.hooks)
+	h.mu.Unlock()
 
-	for _, f := range funcs {
-		f()
+	// priority 越小越先执行，保证 http server 先于 db/cache 关闭
+	sort.SliceStable(hooks, func(i, j int) bool {
+		return hooks[i].priority < hooks[j].priority
+	})
+
+	var errs []error
+	for _, hk := range hooks {
+		hookCtx := ctx
+		cancel := func() {}
+		if hk.timeout > 0 {
+			hookCtx, cancel = context.WithTimeout(ctx, hk.timeout)
+		}
+
+		start := time.Now()
+		err := hk.fn(hookCtx)
+		cancel()
+		log.Printf("shutdown: hook %q finished in %s", hk.name, time.Since(start))
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", hk.name, err))
+		}
+	}
+
+	return joinErrors(errs)
+}
+
+// multiError 把多个 hook 产生的错误聚合成一个 error，方便调用方一次性处理或打印
+type multiError struct {
+	errs []error
+}
+
+func joinErrors(errs []error) error {
+	if len(errs) == 0 {
+		return nil
 	}
+	return &multiError{errs: errs}
+}
+
+func (m *multiError) Error() string {
+	msgs := make([]string, 0, len(m.errs))
+	for _, err := range m.errs {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap 让 errors.Is / errors.As 可以穿透到具体某个 hook 的错误
+func (m *multiError) Unwrap() []error {
+	return m.errs
 }
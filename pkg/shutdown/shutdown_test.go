@@ -0,0 +1,135 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// newTestHook 构造一个不监听真实 OS 信号的 hook，测试里直接往 sigCh 塞一条信号来触发 Close
+func newTestHook() *hook {
+	return &hook{
+		sigCh:    make(chan os.Signal, 1),
+		signalCh: make(chan struct{}),
+	}
+}
+
+func TestHookClosePriorityOrder(t *testing.T) {
+	h := newTestHook()
+
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			return nil
+		}
+	}
+
+	h.Register("cron", 30, time.Second, record("cron"))
+	h.Register("http-server", 10, time.Second, record("http-server"))
+	h.Register("cache", 21, time.Second, record("cache"))
+	h.Register("db", 20, time.Second, record("db"))
+
+	h.sigCh <- syscall.SIGTERM
+	if err := h.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v, want nil", err)
+	}
+
+	want := []string{"http-server", "db", "cache", "cron"}
+	if len(order) != len(want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+	for i, name := range want {
+		if order[i] != name {
+			t.Fatalf("order = %v, want %v", order, want)
+		}
+	}
+}
+
+func TestHookCloseRunsEveryHookAndJoinsErrors(t *testing.T) {
+	h := newTestHook()
+
+	errA := errors.New("a failed")
+	errC := errors.New("c failed")
+
+	var ranB bool
+	h.Register("a", 10, time.Second, func(ctx context.Context) error { return errA })
+	h.Register("b", 20, time.Second, func(ctx context.Context) error { ranB = true; return nil })
+	h.Register("c", 30, time.Second, func(ctx context.Context) error { return errC })
+
+	h.sigCh <- syscall.SIGTERM
+	err := h.Close(context.Background())
+
+	if !ranB {
+		t.Fatal("hook \"b\" should still run even though an earlier hook returned an error")
+	}
+	if err == nil {
+		t.Fatal("Close() error = nil, want a joined error from hooks a and c")
+	}
+	if !errors.Is(err, errA) || !errors.Is(err, errC) {
+		t.Fatalf("Close() error = %v, want it to wrap both errA and errC", err)
+	}
+}
+
+func TestHookClosePerHookTimeoutDoesNotBlockOthers(t *testing.T) {
+	h := newTestHook()
+
+	var ranAfter bool
+	h.Register("slow", 10, 10*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done() // 等自己的 10ms 超时到期
+		return ctx.Err()
+	})
+	h.Register("fast", 20, time.Second, func(ctx context.Context) error {
+		ranAfter = true
+		return nil
+	})
+
+	h.sigCh <- syscall.SIGTERM
+
+	done := make(chan error, 1)
+	go func() { done <- h.Close(context.Background()) }()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("Close() error = nil, want the slow hook's context.DeadlineExceeded")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close() did not return promptly after the slow hook's own timeout elapsed")
+	}
+	if !ranAfter {
+		t.Fatal("hook \"fast\" should still run after the slow hook's own timeout expires")
+	}
+}
+
+func TestHookSignalClosesOnShutdown(t *testing.T) {
+	h := newTestHook()
+	sig := h.Signal()
+
+	select {
+	case <-sig:
+		t.Fatal("Signal() channel closed before shutdown was triggered")
+	default:
+	}
+
+	h.sigCh <- syscall.SIGTERM
+	done := make(chan struct{})
+	go func() {
+		_ = h.Close(context.Background())
+		close(done)
+	}()
+
+	select {
+	case <-sig:
+	case <-time.After(time.Second):
+		t.Fatal("Signal() channel was never closed after Close() started")
+	}
+	<-done
+}